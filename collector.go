@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sampleKey identifies one exported time series: the Prometheus metric name
+// plus its label values joined together.
+type sampleKey struct {
+	metric string
+	labels string
+}
+
+type sample struct {
+	help      string
+	labelKeys []string
+	labelVals []string
+	value     float64
+	lastSeen  time.Time
+}
+
+// OssCollector is a prometheus.Collector that serves the most recently
+// fetched snapshot of every OSS/CloudMonitor metric on each scrape, instead
+// of writing into long-lived GaugeVecs from background goroutines. This
+// avoids stale label sets lingering after a bucket is deleted, and avoids
+// the concurrent writes racing with /metrics scrapes that the old
+// push-gauge pattern in runEnvTask suffered from.
+type OssCollector struct {
+	mu      sync.RWMutex
+	samples map[sampleKey]*sample
+	ttl     time.Duration
+}
+
+func NewOssCollector(ttl time.Duration) *OssCollector {
+	return &OssCollector{
+		samples: make(map[sampleKey]*sample),
+		ttl:     ttl,
+	}
+}
+
+// Set records the current value for one time series, identified by the
+// metric name and its label values. A later call with the same metric and
+// label values overwrites the previous one.
+func (c *OssCollector) Set(metric, help string, labelKeys, labelVals []string, value float64) {
+	key := sampleKey{metric: metric, labels: strings.Join(labelVals, "\xff")}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.samples[key] = &sample{
+		help:      help,
+		labelKeys: labelKeys,
+		labelVals: labelVals,
+		value:     value,
+		lastSeen:  time.Now(),
+	}
+}
+
+// EvictStale drops every series that hasn't been refreshed within the
+// collector's TTL, e.g. because its bucket no longer shows up in
+// DescribeMetricLast or ListBuckets.
+func (c *OssCollector) EvictStale() {
+	cutoff := time.Now().Add(-c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, s := range c.samples {
+		if s.lastSeen.Before(cutoff) {
+			delete(c.samples, key)
+		}
+	}
+}
+
+// Describe intentionally sends nothing: the set of series is dynamic (driven
+// by the configured metrics and the buckets currently reported by Aliyun),
+// which is the documented way to implement an unchecked Collector.
+func (c *OssCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *OssCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for key, s := range c.samples {
+		desc := prometheus.NewDesc(key.metric, s.help, s.labelKeys, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, s.value, s.labelVals...)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func collectSamples(t *testing.T, c *OssCollector) []*dto.Metric {
+	t.Helper()
+
+	ch := make(chan prometheus.Metric, 16)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var out []*dto.Metric
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		out = append(out, pb)
+	}
+	return out
+}
+
+func TestOssCollectorSetAndCollect(t *testing.T) {
+	c := NewOssCollector(time.Minute)
+	c.Set("aliyun_oss_bucket_object_count", "help", []string{"env", "bucketName", "region"}, []string{"prod", "b1", "cn-hangzhou"}, 42)
+
+	samples := collectSamples(t, c)
+	if len(samples) != 1 {
+		t.Fatalf("got %d samples, want 1", len(samples))
+	}
+	if samples[0].GetGauge().GetValue() != 42 {
+		t.Errorf("value = %v, want 42", samples[0].GetGauge().GetValue())
+	}
+}
+
+func TestOssCollectorSetOverwritesSameKey(t *testing.T) {
+	c := NewOssCollector(time.Minute)
+	c.Set("aliyun_oss_bucket_object_count", "help", []string{"env", "bucketName", "region"}, []string{"prod", "b1", "cn-hangzhou"}, 1)
+	c.Set("aliyun_oss_bucket_object_count", "help", []string{"env", "bucketName", "region"}, []string{"prod", "b1", "cn-hangzhou"}, 2)
+
+	samples := collectSamples(t, c)
+	if len(samples) != 1 {
+		t.Fatalf("got %d samples, want 1 (same label set should overwrite, not accumulate)", len(samples))
+	}
+	if samples[0].GetGauge().GetValue() != 2 {
+		t.Errorf("value = %v, want 2", samples[0].GetGauge().GetValue())
+	}
+}
+
+func TestOssCollectorDistinguishesLabelValues(t *testing.T) {
+	c := NewOssCollector(time.Minute)
+	c.Set("aliyun_oss_bucket_object_count", "help", []string{"env", "bucketName", "region"}, []string{"prod", "b1", "cn-hangzhou"}, 1)
+	c.Set("aliyun_oss_bucket_object_count", "help", []string{"env", "bucketName", "region"}, []string{"prod", "b2", "cn-hangzhou"}, 2)
+
+	samples := collectSamples(t, c)
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want 2 (different buckets must be distinct series)", len(samples))
+	}
+}
+
+func TestOssCollectorEvictStale(t *testing.T) {
+	c := NewOssCollector(10 * time.Millisecond)
+	c.Set("aliyun_oss_bucket_object_count", "help", []string{"env", "bucketName", "region"}, []string{"prod", "b1", "cn-hangzhou"}, 1)
+
+	time.Sleep(20 * time.Millisecond)
+	c.EvictStale()
+
+	if samples := collectSamples(t, c); len(samples) != 0 {
+		t.Errorf("got %d samples after EvictStale, want 0 (bucket should have expired)", len(samples))
+	}
+}
+
+func TestOssCollectorEvictStaleKeepsFreshSamples(t *testing.T) {
+	c := NewOssCollector(time.Minute)
+	c.Set("aliyun_oss_bucket_object_count", "help", []string{"env", "bucketName", "region"}, []string{"prod", "b1", "cn-hangzhou"}, 1)
+
+	c.EvictStale()
+
+	if samples := collectSamples(t, c); len(samples) != 1 {
+		t.Errorf("got %d samples after EvictStale, want 1 (sample is within TTL)", len(samples))
+	}
+}
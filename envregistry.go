@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// envRegistry 维护每个 env 对应采集 goroutine 的 cancel 函数，使得 env 可以在
+// 运行时被动态启动/停止（例如随 k8s Secret 的增删改变化），而不再像过去那样
+// 只能在 main 启动时一次性全部 spawn。
+type envRegistry struct {
+	mu     sync.Mutex
+	cancel map[string]context.CancelFunc
+
+	maxConcurrent int
+	qps           float64
+}
+
+func newEnvRegistry(maxConcurrent int, qps float64) *envRegistry {
+	return &envRegistry{
+		cancel:        map[string]context.CancelFunc{},
+		maxConcurrent: maxConcurrent,
+		qps:           qps,
+	}
+}
+
+func (r *envRegistry) start(ctx context.Context, env string, cli AliOssClient, interval time.Duration, metrics []MetricConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, running := r.cancel[env]; running {
+		return
+	}
+
+	envCtx, cancel := context.WithCancel(ctx)
+	r.cancel[env] = cancel
+	go runEnvTask(envCtx, env, cli, interval, metrics, r.maxConcurrent, r.qps)
+}
+
+func (r *envRegistry) stop(env string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cancel, running := r.cancel[env]; running {
+		cancel()
+		delete(r.cancel, env)
+	}
+}
+
+// restart 用于 env 的凭证发生变化（例如 Secret 被更新）的场景：先停掉旧的
+// 采集任务，再用新的 AliOssClient 启动一个新的，避免新旧 goroutine 同时抓取
+// 同一个 env 造成重复采集。
+func (r *envRegistry) restart(ctx context.Context, env string, cli AliOssClient, interval time.Duration, metrics []MetricConfig) {
+	r.stop(env)
+	r.start(ctx, env, cli, interval, metrics)
+}
+
+// envNames 返回当前正在采集的 env 集合，供 /healthz 这类需要随 env 动态
+// 增减（例如来自 k8s Secret 的发现）而实时更新的消费者使用。
+func (r *envRegistry) envNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.cancel))
+	for env := range r.cancel {
+		names = append(names, env)
+	}
+	return names
+}
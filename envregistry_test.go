@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+func envNamesSorted(r *envRegistry) []string {
+	names := r.envNames()
+	sort.Strings(names)
+	return names
+}
+
+// cancelledCtx 返回一个已经取消的 context，让 registry.start 后台 spawn 的
+// runEnvTask 在第一次 select 时就退出，不会发起真正的网络请求，
+// 这样测试只关注 envRegistry 自身的启动/停止记账逻辑。
+func cancelledCtx() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestEnvRegistryStartAddsEnv(t *testing.T) {
+	r := newEnvRegistry(1, 1)
+	r.start(cancelledCtx(), "prod", AliOssClient{}, time.Second, nil)
+
+	if got := envNamesSorted(r); len(got) != 1 || got[0] != "prod" {
+		t.Fatalf("envNames() = %v, want [prod]", got)
+	}
+}
+
+func TestEnvRegistryStartIsIdempotentWhileRunning(t *testing.T) {
+	r := newEnvRegistry(1, 1)
+	ctx := cancelledCtx()
+	r.start(ctx, "prod", AliOssClient{}, time.Second, nil)
+	r.start(ctx, "prod", AliOssClient{}, time.Second, nil)
+
+	if got := envNamesSorted(r); len(got) != 1 {
+		t.Fatalf("envNames() = %v, want a single entry (second start must be a no-op)", got)
+	}
+}
+
+func TestEnvRegistryStop(t *testing.T) {
+	r := newEnvRegistry(1, 1)
+	r.start(cancelledCtx(), "prod", AliOssClient{}, time.Second, nil)
+	r.stop("prod")
+
+	if got := r.envNames(); len(got) != 0 {
+		t.Fatalf("envNames() = %v, want empty after stop", got)
+	}
+}
+
+func TestEnvRegistryStopUnknownEnvIsNoop(t *testing.T) {
+	r := newEnvRegistry(1, 1)
+	r.stop("never-started")
+
+	if got := r.envNames(); len(got) != 0 {
+		t.Fatalf("envNames() = %v, want empty", got)
+	}
+}
+
+func TestEnvRegistryRestartKeepsEnvRunning(t *testing.T) {
+	r := newEnvRegistry(1, 1)
+	ctx := cancelledCtx()
+	r.start(ctx, "prod", AliOssClient{Region: "cn-hangzhou"}, time.Second, nil)
+	r.restart(ctx, "prod", AliOssClient{Region: "cn-beijing"}, time.Second, nil)
+
+	if got := envNamesSorted(r); len(got) != 1 || got[0] != "prod" {
+		t.Fatalf("envNames() = %v, want [prod] after restart", got)
+	}
+}
+
+func TestEnvRegistryRestartOnUnstartedEnvStartsIt(t *testing.T) {
+	r := newEnvRegistry(1, 1)
+	r.restart(cancelledCtx(), "prod", AliOssClient{}, time.Second, nil)
+
+	if got := envNamesSorted(r); len(got) != 1 || got[0] != "prod" {
+		t.Fatalf("envNames() = %v, want [prod]", got)
+	}
+}
+
+func TestEnvRegistryTracksMultipleEnvsIndependently(t *testing.T) {
+	r := newEnvRegistry(1, 1)
+	ctx := cancelledCtx()
+	r.start(ctx, "prod", AliOssClient{}, time.Second, nil)
+	r.start(ctx, "staging", AliOssClient{}, time.Second, nil)
+	r.stop("prod")
+
+	if got := envNamesSorted(r); len(got) != 1 || got[0] != "staging" {
+		t.Fatalf("envNames() = %v, want [staging]", got)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	json "github.com/bytedance/sonic"
+	"go.uber.org/zap"
+)
+
+type envHealthStatus struct {
+	Healthy     bool      `json:"healthy"`
+	LastSuccess time.Time `json:"lastSuccess,omitempty"`
+}
+
+// healthzHandler 根据每个 env 最近一次成功采集的时间判断其健康状态，
+// 超过 staleAfter 没有成功采集过就视为不健康，并整体返回 503。envNames
+// 在每次请求时被调用一次，而不是取一份启动时的快照，这样通过 k8s Secret
+// 动态发现/下线的 env 也会被实时纳入健康检查。
+func healthzHandler(envNames func() []string, staleAfter time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		envs := envNames()
+		status := make(map[string]envHealthStatus, len(envs))
+		allHealthy := true
+
+		for _, env := range envs {
+			last, ok := envLastSuccess(env)
+			healthy := ok && time.Since(last) < staleAfter
+			if !healthy {
+				allHealthy = false
+			}
+			status[env] = envHealthStatus{Healthy: healthy, LastSuccess: last}
+		}
+
+		body, err := json.Marshal(status)
+		if err != nil {
+			logger.Error("Cannot encode healthz response", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !allHealthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(body)
+	}
+}
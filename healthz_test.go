@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	json "github.com/bytedance/sonic"
+)
+
+func TestHealthzHandlerAllHealthy(t *testing.T) {
+	markEnvHealthy("test-healthz-all-healthy")
+
+	handler := healthzHandler(func() []string { return []string{"test-healthz-all-healthy"} }, time.Minute)
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var status map[string]envHealthStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !status["test-healthz-all-healthy"].Healthy {
+		t.Errorf("env should be reported healthy")
+	}
+}
+
+func TestHealthzHandlerUnknownEnvIsUnhealthy(t *testing.T) {
+	handler := healthzHandler(func() []string { return []string{"test-healthz-never-seen"} }, time.Minute)
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	var status map[string]envHealthStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if status["test-healthz-never-seen"].Healthy {
+		t.Errorf("env that never succeeded should be reported unhealthy")
+	}
+}
+
+func TestHealthzHandlerStaleEnvIsUnhealthy(t *testing.T) {
+	markEnvHealthy("test-healthz-stale")
+
+	handler := healthzHandler(func() []string { return []string{"test-healthz-stale"} }, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d (last success is older than staleAfter)", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHealthzHandlerUsesLiveEnvList(t *testing.T) {
+	markEnvHealthy("test-healthz-dynamic")
+
+	envs := []string{}
+	handler := healthzHandler(func() []string { return envs }, time.Minute)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d when no env is registered yet", rec.Code, http.StatusOK)
+	}
+
+	envs = append(envs, "test-healthz-dynamic")
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	var status map[string]envHealthStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if _, ok := status["test-healthz-dynamic"]; !ok {
+		t.Errorf("env added after handler creation should show up on the next request")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+
+	"go.uber.org/zap"
+)
+
+// K8sDiscoveryConfig 开启后，env 的凭证不再只来自 TOML 里的 [envs]，而是
+// 从匹配 LabelSelector 的 Secret 动态发现，便于在不重启 exporter 的情况下
+// 轮换 AccessKey 或接入新的阿里云账号。
+type K8sDiscoveryConfig struct {
+	Enabled           bool   `toml:"enabled"`
+	Namespace         string `toml:"namespace"`
+	LabelSelector     string `toml:"label-selector"`
+	EnvNameAnnotation string `toml:"env-name-annotation"`
+}
+
+// watchRetryBackoff 是一次 List/Watch 失败，或者 watch channel 被关闭后，
+// 重新 List+Watch 之前的等待时间。
+const watchRetryBackoff = 5 * time.Second
+
+func envNameFromSecret(secret *corev1.Secret, annotation string) (string, bool) {
+	name, ok := secret.Annotations[annotation]
+	return name, ok && name != ""
+}
+
+func aliOssClientFromSecret(secret *corev1.Secret) AliOssClient {
+	return AliOssClient{
+		Region:          string(secret.Data["region"]),
+		AccessKeyId:     string(secret.Data["accessKeyId"]),
+		AccessKeySecret: string(secret.Data["accessKeySecret"]),
+	}
+}
+
+// watchEnvSecrets 先 List 一次 cfg.Namespace 下匹配 cfg.LabelSelector 的
+// Secret 并立刻注册它们描述的 env——裸 Watch 不会重放 watch 建立前就已经
+// 存在的对象，所以没有这一步的话，已经存在的账号只有等对应 Secret 下次被
+// 修改才会被发现。随后进入一个持续 List+Watch 的循环。
+func watchEnvSecrets(ctx context.Context, cfg K8sDiscoveryConfig, registry *envRegistry, interval time.Duration, metrics []MetricConfig) error {
+	restCfg, err := rest.InClusterConfig()
+	if err != nil {
+		return err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+
+	secrets := clientset.CoreV1().Secrets(cfg.Namespace)
+
+	if _, err := listAndRegisterSecrets(ctx, secrets, cfg, registry, interval, metrics); err != nil {
+		return err
+	}
+
+	go runSecretWatchLoop(ctx, secrets, cfg, registry, interval, metrics)
+	return nil
+}
+
+// listAndRegisterSecrets 列出当前匹配 cfg.LabelSelector 的 Secret，为每一个
+// (重新) 启动对应的 env 采集任务，并返回这次 List 的 resourceVersion，供
+// 紧接着的 Watch 从这个版本开始续接，不漏掉中间的变化。
+func listAndRegisterSecrets(ctx context.Context, secrets corev1client.SecretInterface, cfg K8sDiscoveryConfig, registry *envRegistry, interval time.Duration, metrics []MetricConfig) (string, error) {
+	list, err := secrets.List(ctx, metav1.ListOptions{LabelSelector: cfg.LabelSelector})
+	if err != nil {
+		return "", err
+	}
+
+	for i := range list.Items {
+		secret := &list.Items[i]
+		env, ok := envNameFromSecret(secret, cfg.EnvNameAnnotation)
+		if !ok {
+			logger.Error("secret missing env name annotation, skipping", zap.String("secret", secret.Name))
+			continue
+		}
+
+		registry.restart(ctx, env, aliOssClientFromSecret(secret), interval, metrics)
+		logger.Info("env (re)started from k8s secret", zap.String("env", env), zap.String("secret", secret.Name))
+	}
+
+	return list.ResourceVersion, nil
+}
+
+// runSecretWatchLoop 持续 watch Secret 变化；watch 因为任何原因结束时
+// （API server 的 watch 超时、网络问题……）都会重新 List+Watch，而不是像
+// 之前那样只 watch 一次、断开后就永久停止发现新 env。
+func runSecretWatchLoop(ctx context.Context, secrets corev1client.SecretInterface, cfg K8sDiscoveryConfig, registry *envRegistry, interval time.Duration, metrics []MetricConfig) {
+	for ctx.Err() == nil {
+		resourceVersion, err := listAndRegisterSecrets(ctx, secrets, cfg, registry, interval, metrics)
+		if err != nil {
+			logger.Error("Cannot list k8s secrets for discovery, retrying", zap.Error(err))
+			sleepOrDone(ctx, watchRetryBackoff)
+			continue
+		}
+
+		watcher, err := secrets.Watch(ctx, metav1.ListOptions{
+			LabelSelector:   cfg.LabelSelector,
+			ResourceVersion: resourceVersion,
+		})
+		if err != nil {
+			logger.Error("Cannot start k8s secret watch, retrying", zap.Error(err))
+			sleepOrDone(ctx, watchRetryBackoff)
+			continue
+		}
+
+		consumeSecretEvents(ctx, watcher, cfg, registry, interval, metrics)
+		watcher.Stop()
+		sleepOrDone(ctx, watchRetryBackoff)
+	}
+}
+
+// consumeSecretEvents 消费一次 watch 会话里的所有事件，直到 channel 被关闭
+// 或 ctx 被取消才返回。
+func consumeSecretEvents(ctx context.Context, watcher watch.Interface, cfg K8sDiscoveryConfig, registry *envRegistry, interval time.Duration, metrics []MetricConfig) {
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+
+			secret, ok := event.Object.(*corev1.Secret)
+			if !ok {
+				continue
+			}
+
+			env, ok := envNameFromSecret(secret, cfg.EnvNameAnnotation)
+			if !ok {
+				logger.Error("secret missing env name annotation, skipping", zap.String("secret", secret.Name))
+				continue
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				registry.restart(ctx, env, aliOssClientFromSecret(secret), interval, metrics)
+				logger.Info("env (re)started from k8s secret", zap.String("env", env), zap.String("secret", secret.Name))
+			case watch.Deleted:
+				registry.stop(env)
+				logger.Info("env stopped, backing secret was deleted", zap.String("env", env), zap.String("secret", secret.Name))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func sleepOrDone(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	applyconfigurationscorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const testEnvAnnotation = "alioss-bucket-stats/env"
+
+// fakeSecretClient 是 corev1client.SecretInterface 的最小手写实现，只需要支持
+// List（按 LabelSelector 过滤，携带固定的 ResourceVersion），其余方法在这一
+// 系列测试里都用不到，返回 not implemented 即可。
+type fakeSecretClient struct {
+	items           []corev1.Secret
+	resourceVersion string
+}
+
+var _ corev1client.SecretInterface = (*fakeSecretClient)(nil)
+
+func (f *fakeSecretClient) List(ctx context.Context, opts metav1.ListOptions) (*corev1.SecretList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &corev1.SecretList{}
+	list.ResourceVersion = f.resourceVersion
+	for _, secret := range f.items {
+		if selector.Matches(labels.Set(secret.Labels)) {
+			list.Items = append(list.Items, secret)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeSecretClient) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSecretClient) Create(ctx context.Context, secret *corev1.Secret, opts metav1.CreateOptions) (*corev1.Secret, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSecretClient) Update(ctx context.Context, secret *corev1.Secret, opts metav1.UpdateOptions) (*corev1.Secret, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSecretClient) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return errors.New("not implemented")
+}
+
+func (f *fakeSecretClient) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	return errors.New("not implemented")
+}
+
+func (f *fakeSecretClient) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSecretClient) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*corev1.Secret, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSecretClient) Apply(ctx context.Context, secret *applyconfigurationscorev1.SecretApplyConfiguration, opts metav1.ApplyOptions) (*corev1.Secret, error) {
+	return nil, errors.New("not implemented")
+}
+
+func secretFixture(name, env string, labelSet map[string]string) corev1.Secret {
+	return corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "monitoring",
+			Labels:      labelSet,
+			Annotations: map[string]string{testEnvAnnotation: env},
+		},
+		Data: map[string][]byte{
+			"region":          []byte("cn-hangzhou"),
+			"accessKeyId":     []byte("ak"),
+			"accessKeySecret": []byte("sk"),
+		},
+	}
+}
+
+func testK8sDiscoveryConfig() K8sDiscoveryConfig {
+	return K8sDiscoveryConfig{
+		Enabled:           true,
+		Namespace:         "monitoring",
+		LabelSelector:     "app=alioss-bucket-stats",
+		EnvNameAnnotation: testEnvAnnotation,
+	}
+}
+
+func TestListAndRegisterSecretsRegistersMatchingSecrets(t *testing.T) {
+	matching := map[string]string{"app": "alioss-bucket-stats"}
+	client := &fakeSecretClient{
+		resourceVersion: "1000",
+		items: []corev1.Secret{
+			secretFixture("prod-secret", "prod", matching),
+			secretFixture("staging-secret", "staging", matching),
+			secretFixture("unrelated-secret", "unrelated", map[string]string{"app": "other"}),
+		},
+	}
+
+	cfg := testK8sDiscoveryConfig()
+	registry := newEnvRegistry(1, 1)
+
+	if _, err := listAndRegisterSecrets(cancelledCtx(), client, cfg, registry, time.Second, nil); err != nil {
+		t.Fatalf("listAndRegisterSecrets: %v", err)
+	}
+
+	got := envNamesSorted(registry)
+	want := []string{"prod", "staging"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("envNames() = %v, want %v (label selector must filter out non-matching secrets)", got, want)
+	}
+}
+
+func TestListAndRegisterSecretsSkipsSecretsWithoutAnnotation(t *testing.T) {
+	matching := map[string]string{"app": "alioss-bucket-stats"}
+	secret := secretFixture("no-annotation-secret", "whatever", matching)
+	delete(secret.Annotations, testEnvAnnotation)
+
+	client := &fakeSecretClient{items: []corev1.Secret{secret}}
+	cfg := testK8sDiscoveryConfig()
+	registry := newEnvRegistry(1, 1)
+
+	if _, err := listAndRegisterSecrets(cancelledCtx(), client, cfg, registry, time.Second, nil); err != nil {
+		t.Fatalf("listAndRegisterSecrets: %v", err)
+	}
+
+	if got := registry.envNames(); len(got) != 0 {
+		t.Fatalf("envNames() = %v, want empty (secret missing the env annotation must be skipped)", got)
+	}
+}
+
+func TestListAndRegisterSecretsReturnsListResourceVersion(t *testing.T) {
+	matching := map[string]string{"app": "alioss-bucket-stats"}
+	client := &fakeSecretClient{
+		resourceVersion: "42",
+		items:           []corev1.Secret{secretFixture("prod-secret", "prod", matching)},
+	}
+	cfg := testK8sDiscoveryConfig()
+	registry := newEnvRegistry(1, 1)
+
+	rv, err := listAndRegisterSecrets(cancelledCtx(), client, cfg, registry, time.Second, nil)
+	if err != nil {
+		t.Fatalf("listAndRegisterSecrets: %v", err)
+	}
+	if rv != "42" {
+		t.Errorf("resourceVersion = %q, want %q so a subsequent Watch can resume from this List", rv, "42")
+	}
+}
+
+func TestConsumeSecretEventsAddAndDelete(t *testing.T) {
+	cfg := testK8sDiscoveryConfig()
+	registry := newEnvRegistry(1, 1)
+	watcher := watch.NewFake()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumeSecretEvents(context.Background(), watcher, cfg, registry, time.Second, nil)
+	}()
+
+	secret := secretFixture("prod-secret", "prod", nil)
+	watcher.Add(&secret)
+	waitForEnvNames(t, registry, []string{"prod"})
+
+	watcher.Delete(&secret)
+	waitForEnvNames(t, registry, nil)
+
+	watcher.Stop()
+	<-done
+}
+
+func waitForEnvNames(t *testing.T, registry *envRegistry, want []string) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		got := envNamesSorted(registry)
+		sort.Strings(want)
+		if len(got) == len(want) {
+			match := true
+			for i := range got {
+				if got[i] != want[i] {
+					match = false
+					break
+				}
+			}
+			if match {
+				return
+			}
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("envNames() = %v, want %v", got, want)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -23,17 +23,26 @@ var (
 	logger     *zap.Logger
 	configFile = flag.String("config", "config.toml", "配置文件")
 
-	labelNames   = []string{"env", "bucketName", "region", "type"}
-	bucketGaugue = NewGauge("aliyun_oss_bucket_info", "aliyun oss bucket info", labelNames)
-
-	metricList  = []string{
-		"MeteringStorageUtilization",	// 存储大小
-		"MeteringInternetTX",		// 公网流出计量流量
-		"MeteringCdnTX",		// cdn流出计量流量
-		"MeteringSyncTX",		// 跨区域复制流出计量流量
-	}
+	labelNames = []string{"env", "bucketName", "region", "type"}
+
+	// staleAfterIntervals 控制一个时间序列在多少个抓取周期内没有被刷新后
+	// 会被判定为过期并从 /metrics 输出中剔除（例如对应的 bucket 被删除了）。
+	staleAfterIntervals = 3
+
+	ossCollector *OssCollector
 )
 
+// defaultMetrics 复现了重构前硬编码的四个 acs_oss_dashboard 指标，
+// 保证未配置 [[metrics]] 时行为不变。
+func defaultMetrics() []MetricConfig {
+	return []MetricConfig{
+		{Namespace: "acs_oss_dashboard", MetricName: "MeteringStorageUtilization", PrometheusName: "aliyun_oss_bucket_info", Help: "aliyun oss bucket info"}, // 存储大小
+		{Namespace: "acs_oss_dashboard", MetricName: "MeteringInternetTX", PrometheusName: "aliyun_oss_bucket_info", Help: "aliyun oss bucket info"},         // 公网流出计量流量
+		{Namespace: "acs_oss_dashboard", MetricName: "MeteringCdnTX", PrometheusName: "aliyun_oss_bucket_info", Help: "aliyun oss bucket info"},              // cdn流出计量流量
+		{Namespace: "acs_oss_dashboard", MetricName: "MeteringSyncTX", PrometheusName: "aliyun_oss_bucket_info", Help: "aliyun oss bucket info"},             // 跨区域复制流出计量流量
+	}
+}
+
 func init() {
 	logger, _ = zap.NewProduction()
 }
@@ -42,6 +51,12 @@ type Config struct {
 	ListenAddress         string                  `toml:"listen-address"`
 	RefreshIntervalSecond int                     `toml:"refresh-interval-second"`
 	Envs                  map[string]AliOssClient `toml:"envs"`
+	Metrics               []MetricConfig          `toml:"metrics"`
+	K8sDiscovery          K8sDiscoveryConfig      `toml:"k8s-discovery"`
+	// MaxConcurrentRequests 和 QPS 控制每个 env 对阿里云 API 的请求并发度和
+	// 速率，避免多个 env/指标同时请求时冲垮 CMS 的 QPS 限制；留空时取默认值。
+	MaxConcurrentRequests int     `toml:"max-concurrent-requests"`
+	QPS                   float64 `toml:"qps"`
 }
 
 type AliOssClient struct {
@@ -50,6 +65,18 @@ type AliOssClient struct {
 	AccessKeySecret string `toml:"accessKeySecret"`
 }
 
+// MetricConfig 描述一个要从 CloudMonitor 拉取的指标：从哪个 namespace 下的
+// 哪个 metric_name 拉取，导出为哪个 Prometheus 指标名，以及可选的维度过滤。
+type MetricConfig struct {
+	Namespace      string `toml:"namespace"`
+	MetricName     string `toml:"metric-name"`
+	PrometheusName string `toml:"prometheus-name"`
+	Help           string `toml:"help"`
+	// DimensionFilter 原样传给 DescribeMetricLastRequest.Dimensions，
+	// 例如 `[{"userId":"xxx"}]`，为空表示不过滤。
+	DimensionFilter string `toml:"dimension-filter,omitempty"`
+}
+
 type AliOssBucketResp struct {
 	Timestamp   int64   `json:"timestamp"`
 	UserId      string  `json:"userId"`
@@ -70,53 +97,89 @@ func newCmsClient(region, accessKeyId, accessKeySecret string) *cms.Client {
 	return cmsClient
 }
 
-func getOssBucketMetrics(ctx context.Context, client *cms.Client, env string, metricName, metricType string) error {
+func getOssBucketMetrics(ctx context.Context, client *cms.Client, env string, mc MetricConfig) error {
+	start := time.Now()
+	defer func() {
+		scrapeDurationSeconds.WithLabelValues(env, mc.MetricName).Observe(time.Since(start).Seconds())
+	}()
+
 	bucketResp := make([]AliOssBucketResp, 0)
 
 	req := cms.CreateDescribeMetricLastRequest()
 	req.Scheme = "https"
 	req.ConnectTimeout = time.Duration(30) * time.Second
 	req.ReadTimeout = time.Duration(120) * time.Second
-	req.Namespace = "acs_oss_dashboard"
-	req.MetricName = metricName
+	req.Namespace = mc.Namespace
+	req.MetricName = mc.MetricName
+	if mc.DimensionFilter != "" {
+		req.Dimensions = mc.DimensionFilter
+	}
 
+	cmsAPICallsTotal.WithLabelValues(env, mc.MetricName).Inc()
 	resp, err := client.DescribeMetricLast(req)
 	if err != nil {
 		logger.Error("Encounter response error from Aliyun:", zap.Error(err))
+		scrapeErrorsTotal.WithLabelValues(env, mc.MetricName, "cms_api_error").Inc()
 		return err
 	} else if err := json.Unmarshal([]byte(resp.Datapoints), &bucketResp); err != nil {
 		logger.Error("Cannot decode json response: ", zap.Error(err))
+		scrapeErrorsTotal.WithLabelValues(env, mc.MetricName, "decode_error").Inc()
 		return err
 	}
 
 	for _, val := range bucketResp {
-		bucketGaugue.WithLabelValues(env, val.BucketName, val.Region, metricType).Set(val.Value)
+		labelVals := []string{env, val.BucketName, val.Region, mc.MetricName}
+		ossCollector.Set(mc.PrometheusName, mc.Help, labelNames, labelVals, val.Value)
 	}
+
+	lastSuccessTimestampSeconds.WithLabelValues(env, mc.MetricName).Set(float64(time.Now().Unix()))
+	markEnvHealthy(env)
 	return nil
 }
 
-func runEnvTask(ctx context.Context, env string, cli AliOssClient, interval time.Duration) {
+func runEnvTask(ctx context.Context, env string, cli AliOssClient, interval time.Duration, metrics []MetricConfig, maxConcurrent int, qps float64) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	client := newCmsClient(cli.Region, cli.AccessKeyId, cli.AccessKeySecret)
 
-	// 程序启动就先执行一次，然后再 Ticker 周期性运行
-	// 参考文档：https://cloudmonitornext.console.aliyun.com/resources-list/metric/acs_oss_dashboard/oss/all
-	for _, mn := range metricList {
-		go func(metricName string) {
-			getOssBucketMetrics(ctx, client, env, metricName, metricName)
-		}(mn)
+	ossClient, err := newOssClient(cli.Region, cli.AccessKeyId, cli.AccessKeySecret)
+	if err != nil {
+		logger.Error("Cannot create OSS SDK client:", zap.String("env", env), zap.Error(err))
 	}
 
+	gate := newRequestGate(qps, maxConcurrent)
+
+	runTick := func() {
+		// 程序启动就先执行一次，然后再 Ticker 周期性运行
+		// 参考文档：https://cloudmonitornext.console.aliyun.com/resources-list/metric/acs_oss_dashboard/oss/all
+		for _, mc := range metrics {
+			go func(mc MetricConfig) {
+				reqCtx, cancel := context.WithTimeout(ctx, interval)
+				defer cancel()
+				gate.Do(reqCtx, env, mc.MetricName, func(reqCtx context.Context) error {
+					return getOssBucketMetrics(reqCtx, client, env, mc)
+				})
+			}(mc)
+		}
+
+		if ossClient != nil {
+			go func() {
+				reqCtx, cancel := context.WithTimeout(ctx, interval)
+				defer cancel()
+				gate.Do(reqCtx, env, ossStatsMetricName, func(reqCtx context.Context) error {
+					return getOssBucketStats(reqCtx, ossClient, env, cli.Region)
+				})
+			}()
+		}
+	}
+
+	runTick()
+
 	for {
 		select {
 		case <-ticker.C:
-			for _, mn := range metricList {
-				go func(metricName string) {
-					getOssBucketMetrics(ctx, client, env, metricName, metricName)
-				}(mn)
-			}
+			runTick()
 		case <-ctx.Done():
 			logger.Info("getOssBucketMetrics ctx done")
 			return
@@ -124,17 +187,6 @@ func runEnvTask(ctx context.Context, env string, cli AliOssClient, interval time
 	}
 }
 
-func NewGauge(name, help string, labels []string) *prometheus.GaugeVec {
-	gauge := prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: "",
-			Name:      name,
-			Help:      help,
-		}, labels)
-	prometheus.MustRegister(gauge)
-	return gauge
-}
-
 func main() {
 	flag.Parse()
 	defer logger.Sync()
@@ -146,12 +198,28 @@ func main() {
 		os.Exit(1)
 	}
 
+	if len(config.Metrics) == 0 {
+		config.Metrics = defaultMetrics()
+	}
+
+	if config.RefreshIntervalSecond <= 0 {
+		logger.Error("refresh-interval-second 必须大于 0", zap.Int("refresh-interval-second", config.RefreshIntervalSecond))
+		os.Exit(1)
+	}
+
+	interval := time.Duration(config.RefreshIntervalSecond) * time.Second
+	ossCollector = NewOssCollector(interval * time.Duration(staleAfterIntervals))
+	prometheus.MustRegister(ossCollector)
+
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	registry := newEnvRegistry(config.MaxConcurrentRequests, config.QPS)
+
 	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/healthz", healthzHandler(registry.envNames, 2*interval))
 	httpServer := http.Server{
 		Addr: config.ListenAddress,
 	}
@@ -163,9 +231,27 @@ func main() {
 		httpServer.Shutdown(ctx)
 	}(ctx)
 
+	go func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ossCollector.EvictStale()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(ctx)
+
 	for env, AliOssCli := range config.Envs {
-		dur := time.Duration(config.RefreshIntervalSecond) * time.Second
-		go runEnvTask(ctx, env, AliOssCli, dur)
+		registry.start(ctx, env, AliOssCli, interval, config.Metrics)
+	}
+
+	if config.K8sDiscovery.Enabled {
+		if err := watchEnvSecrets(ctx, config.K8sDiscovery, registry, interval, config.Metrics); err != nil {
+			logger.Error("Cannot start k8s secret discovery:", zap.Error(err))
+		}
 	}
 
 	logger.Info("http server started")
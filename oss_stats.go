@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"go.uber.org/zap"
+)
+
+// ossStatsMetricName 是 getOssBucketStats 在自监控指标里用到的 metric 标签值，
+// 因为它一次调用会产出多个 bucket 级别的 gauge，不对应单个 CloudMonitor metric_name。
+const ossStatsMetricName = "oss_bucket_stats"
+
+var (
+	bucketLabelNames       = []string{"env", "bucketName", "region"}
+	storageClassLabelNames = []string{"env", "bucketName", "region", "class"}
+)
+
+func newOssClient(region, accessKeyId, accessKeySecret string) (*oss.Client, error) {
+	endpoint := fmt.Sprintf("https://oss-%s.aliyuncs.com", region)
+	return oss.New(endpoint, accessKeyId, accessKeySecret)
+}
+
+// listAllBuckets 翻页拉取账号下的全部 bucket：ListBuckets 每次最多返回一页
+// （IsTruncated/NextMarker 标识是否还有下一页），账号 bucket 数超过一页时
+// 不跟进分页就只能拿到第一页的结果。
+func listAllBuckets(client *oss.Client) ([]oss.BucketProperties, error) {
+	var all []oss.BucketProperties
+	marker := ""
+
+	for {
+		resp, err := client.ListBuckets(oss.Marker(marker))
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, resp.Buckets...)
+		if !resp.IsTruncated {
+			return all, nil
+		}
+		marker = resp.NextMarker
+	}
+}
+
+// getOssBucketStats 通过 OSS SDK 直接拉取 bucket 级别的容量/对象数等统计信息，
+// 这些指标在 acs_oss_dashboard 监控项下是拿不到的。
+func getOssBucketStats(ctx context.Context, client *oss.Client, env, region string) error {
+	start := time.Now()
+	defer func() {
+		scrapeDurationSeconds.WithLabelValues(env, ossStatsMetricName).Observe(time.Since(start).Seconds())
+	}()
+
+	buckets, err := listAllBuckets(client)
+	if err != nil {
+		logger.Error("Encounter response error from Aliyun OSS SDK:", zap.Error(err))
+		scrapeErrorsTotal.WithLabelValues(env, ossStatsMetricName, "oss_api_error").Inc()
+		return err
+	}
+
+	for _, b := range buckets {
+		stat, err := client.GetBucketStat(b.Name)
+		if err != nil {
+			logger.Error("Cannot get bucket stat:", zap.String("bucketName", b.Name), zap.Error(err))
+			scrapeErrorsTotal.WithLabelValues(env, ossStatsMetricName, "oss_api_error").Inc()
+			continue
+		}
+
+		labelVals := []string{env, b.Name, region}
+		ossCollector.Set("aliyun_oss_bucket_object_count", "aliyun oss bucket object count", bucketLabelNames, labelVals, float64(stat.ObjectCount))
+		ossCollector.Set("aliyun_oss_bucket_multipart_upload_count", "aliyun oss bucket multipart upload count", bucketLabelNames, labelVals, float64(stat.MultipartUploadCount))
+		ossCollector.Set("aliyun_oss_bucket_live_channel_count", "aliyun oss bucket live channel count", bucketLabelNames, labelVals, float64(stat.LiveChannelCount))
+
+		ossCollector.Set("aliyun_oss_bucket_storage_class_bytes", "aliyun oss bucket size in bytes by storage class", storageClassLabelNames, []string{env, b.Name, region, "Standard"}, float64(stat.StandardStorage))
+		ossCollector.Set("aliyun_oss_bucket_storage_class_bytes", "aliyun oss bucket size in bytes by storage class", storageClassLabelNames, []string{env, b.Name, region, "IA"}, float64(stat.InfrequentAccessStorage))
+		ossCollector.Set("aliyun_oss_bucket_storage_class_bytes", "aliyun oss bucket size in bytes by storage class", storageClassLabelNames, []string{env, b.Name, region, "Archive"}, float64(stat.ArchiveStorage))
+		ossCollector.Set("aliyun_oss_bucket_storage_class_bytes", "aliyun oss bucket size in bytes by storage class", storageClassLabelNames, []string{env, b.Name, region, "ColdArchive"}, float64(stat.ColdArchiveStorage))
+	}
+
+	lastSuccessTimestampSeconds.WithLabelValues(env, ossStatsMetricName).Set(float64(time.Now().Unix()))
+	markEnvHealthy(env)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+// newListBucketsPage 按 oss SDK 解析的 ListAllMyBucketsResult 格式手工拼出
+// 一页 ListBuckets 的 XML 响应。
+func newListBucketsPage(names []string, truncated bool, nextMarker string) string {
+	body := `<?xml version="1.0" encoding="UTF-8"?><ListAllMyBucketsResult>`
+	if truncated {
+		body += `<IsTruncated>true</IsTruncated><NextMarker>` + nextMarker + `</NextMarker>`
+	} else {
+		body += `<IsTruncated>false</IsTruncated>`
+	}
+	body += `<Buckets>`
+	for _, name := range names {
+		body += `<Bucket><Name>` + name + `</Name></Bucket>`
+	}
+	body += `</Buckets></ListAllMyBucketsResult>`
+	return body
+}
+
+func TestListAllBucketsFollowsPagination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		if r.URL.Query().Get("marker") == "" {
+			w.Write([]byte(newListBucketsPage([]string{"b1", "b2"}, true, "b2")))
+			return
+		}
+		w.Write([]byte(newListBucketsPage([]string{"b3"}, false, "")))
+	}))
+	defer srv.Close()
+
+	client, err := oss.New(srv.URL, "ak", "sk")
+	if err != nil {
+		t.Fatalf("oss.New: %v", err)
+	}
+
+	buckets, err := listAllBuckets(client)
+	if err != nil {
+		t.Fatalf("listAllBuckets: %v", err)
+	}
+
+	want := []string{"b1", "b2", "b3"}
+	if len(buckets) != len(want) {
+		t.Fatalf("got %d buckets, want %d", len(buckets), len(want))
+	}
+	for i, name := range want {
+		if buckets[i].Name != name {
+			t.Errorf("buckets[%d].Name = %q, want %q", i, buckets[i].Name, name)
+		}
+	}
+}
+
+func TestListAllBucketsSinglePage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(newListBucketsPage([]string{"only"}, false, "")))
+	}))
+	defer srv.Close()
+
+	client, err := oss.New(srv.URL, "ak", "sk")
+	if err != nil {
+		t.Fatalf("oss.New: %v", err)
+	}
+
+	buckets, err := listAllBuckets(client)
+	if err != nil {
+		t.Fatalf("listAllBuckets: %v", err)
+	}
+	if len(buckets) != 1 || buckets[0].Name != "only" {
+		t.Fatalf("got %v, want a single bucket named %q", buckets, "only")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	maxRetries     = 3
+	initialBackoff = 500 * time.Millisecond
+
+	// rateLimitBurst 限制令牌桶的突发容量：只要放到 maxConcurrent 就会让
+	// 每个 tick 的一次性 fan-out 把全部请求当作"突发"一口气放行，qps 形同虚设。
+	rateLimitBurst = 1
+)
+
+// requestGate 限制单个 env 对阿里云 API 的并发度和 QPS：所有经过它的调用都要
+// 先拿到一个限速器令牌，再拿到一个并发槽位，才会真正发起请求。
+type requestGate struct {
+	limiter *rate.Limiter
+	sem     chan struct{}
+}
+
+func newRequestGate(qps float64, maxConcurrent int) *requestGate {
+	if qps <= 0 {
+		qps = 5
+	}
+	if maxConcurrent <= 0 {
+		maxConcurrent = 5
+	}
+
+	return &requestGate{
+		limiter: rate.NewLimiter(rate.Limit(qps), rateLimitBurst),
+		sem:     make(chan struct{}, maxConcurrent),
+	}
+}
+
+// isRetryableAliyunError 判断阿里云 SDK 返回的错误是否值得退避重试，
+// 目前只处理最常见的限流/服务不可用错误码。
+func isRetryableAliyunError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Throttling") || strings.Contains(msg, "ServiceUnavailable")
+}
+
+// acquire 等待限速令牌和一个并发槽位，只在持有槽位期间执行一次 fn 调用，
+// 不会跨越重试之间的退避等待占用槽位，否则一个被限流的请求会在退避期间
+// 饿死同一 env 下其他本可以立即执行的请求。
+func (g *requestGate) acquire(ctx context.Context, env, metric string, fn func(ctx context.Context) error) error {
+	if err := g.limiter.Wait(ctx); err != nil {
+		// Wait 在预计等待会超过 ctx 的截止时间时会提前返回错误，此时 ctx.Err()
+		// 仍为 nil，同样算作被限速丢弃；只有 ctx 被主动取消时不计数。
+		if ctx.Err() != context.Canceled {
+			requestsDroppedTotal.WithLabelValues(env, metric, "rate_limited").Inc()
+		}
+		return err
+	}
+
+	select {
+	case g.sem <- struct{}{}:
+		defer func() { <-g.sem }()
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			requestsDroppedTotal.WithLabelValues(env, metric, "pool_full").Inc()
+		}
+		return ctx.Err()
+	}
+
+	return fn(ctx)
+}
+
+// Do 在拿到限速令牌和并发槽位后执行 fn，并对可重试的阿里云错误做指数退避
+// 重试；env/metric 仅用于上报 exporter 自身的 dropped/retried 计数。
+func (g *requestGate) Do(ctx context.Context, env, metric string, fn func(ctx context.Context) error) error {
+	backoff := initialBackoff
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		err = g.acquire(ctx, env, metric, fn)
+		if err == nil || !isRetryableAliyunError(err) {
+			return err
+		}
+
+		if attempt == maxRetries {
+			break
+		}
+
+		requestsRetriedTotal.WithLabelValues(env, metric).Inc()
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIsRetryableAliyunError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"throttling", errors.New(`SDK.ServerError ErrCode: Throttling.User`), true},
+		{"service unavailable", errors.New(`SDK.ServerError ErrCode: ServiceUnavailable`), true},
+		{"auth error", errors.New(`SDK.ServerError ErrCode: InvalidAccessKeyId.NotFound`), false},
+		{"generic network error", errors.New(`dial tcp: i/o timeout`), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRetryableAliyunError(tc.err); got != tc.want {
+				t.Errorf("isRetryableAliyunError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequestGateDoRetriesOnThrottling(t *testing.T) {
+	g := newRequestGate(1000, 4)
+
+	attempts := 0
+	throttling := errors.New("Throttling.User")
+
+	err := g.Do(context.Background(), "prod", "MeteringStorageUtilization", func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return throttling
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Do returned %v, want nil after eventual success", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3 (2 retries then success)", attempts)
+	}
+}
+
+func TestRequestGateDoGivesUpAfterMaxRetries(t *testing.T) {
+	g := newRequestGate(1000, 4)
+
+	attempts := 0
+	throttling := errors.New("Throttling.User")
+
+	err := g.Do(context.Background(), "prod", "MeteringStorageUtilization", func(ctx context.Context) error {
+		attempts++
+		return throttling
+	})
+
+	if !errors.Is(err, throttling) {
+		t.Errorf("err = %v, want the underlying throttling error", err)
+	}
+	if attempts != maxRetries+1 {
+		t.Errorf("attempts = %d, want %d (initial attempt + maxRetries retries)", attempts, maxRetries+1)
+	}
+}
+
+func TestRequestGateDoDoesNotRetryNonRetryableErrors(t *testing.T) {
+	g := newRequestGate(1000, 4)
+
+	attempts := 0
+	permanent := errors.New("InvalidAccessKeyId.NotFound")
+
+	err := g.Do(context.Background(), "prod", "MeteringStorageUtilization", func(ctx context.Context) error {
+		attempts++
+		return permanent
+	})
+
+	if !errors.Is(err, permanent) {
+		t.Errorf("err = %v, want the underlying permanent error", err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 (non-retryable errors must not be retried)", attempts)
+	}
+}
+
+func TestRequestGateDoRespectsContextCancellation(t *testing.T) {
+	g := newRequestGate(1000, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := g.Do(ctx, "prod", "MeteringStorageUtilization", func(ctx context.Context) error {
+		t.Fatal("fn should not be called once the context is already cancelled")
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestRequestGateDoLimitsConcurrency(t *testing.T) {
+	g := newRequestGate(1000, 1)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		g.Do(context.Background(), "prod", "m1", func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := g.Do(ctx, "prod", "m2", func(ctx context.Context) error {
+		t.Fatal("second call should not get a slot while the first is still in flight")
+		return nil
+	})
+
+	close(release)
+	<-done
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded while the single slot is occupied", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	scrapeDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "aliyun_oss_exporter_scrape_duration_seconds",
+		Help: "Time spent on one CloudMonitor/OSS SDK scrape, by env and metric",
+	}, []string{"env", "metric"})
+
+	scrapeErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aliyun_oss_exporter_scrape_errors_total",
+		Help: "Total number of failed scrapes, by env, metric and failure reason",
+	}, []string{"env", "metric", "reason"})
+
+	lastSuccessTimestampSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aliyun_oss_exporter_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last successful scrape, by env and metric",
+	}, []string{"env", "metric"})
+
+	cmsAPICallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aliyun_oss_exporter_cms_api_calls_total",
+		Help: "Total number of DescribeMetricLast calls made to Aliyun CMS, by env and metric",
+	}, []string{"env", "metric"})
+
+	requestsDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aliyun_oss_exporter_requests_dropped_total",
+		Help: "Total number of Aliyun API calls dropped by the per-env request gate, by env, metric and reason",
+	}, []string{"env", "metric", "reason"})
+
+	requestsRetriedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aliyun_oss_exporter_requests_retried_total",
+		Help: "Total number of Aliyun API calls retried after a throttling/unavailable error, by env and metric",
+	}, []string{"env", "metric"})
+)
+
+func init() {
+	prometheus.MustRegister(scrapeDurationSeconds, scrapeErrorsTotal, lastSuccessTimestampSeconds, cmsAPICallsTotal,
+		requestsDroppedTotal, requestsRetriedTotal)
+}
+
+// envHealth 记录每个 env 最近一次成功采集的时间，供 /healthz 直接查询，
+// 而不必反查 Prometheus 内部状态。
+var (
+	envHealthMu sync.RWMutex
+	envHealth   = map[string]time.Time{}
+)
+
+func markEnvHealthy(env string) {
+	envHealthMu.Lock()
+	defer envHealthMu.Unlock()
+	envHealth[env] = time.Now()
+}
+
+func envLastSuccess(env string) (time.Time, bool) {
+	envHealthMu.RLock()
+	defer envHealthMu.RUnlock()
+	t, ok := envHealth[env]
+	return t, ok
+}